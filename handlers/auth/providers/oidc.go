@@ -0,0 +1,161 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcProvider is a generic OpenID Connect provider driven entirely by
+// discovery. Google, Microsoft, and any other standards-compliant IdP
+// are just an oidcProvider constructed with a different IssuerURL.
+type oidcProvider struct {
+	name        string
+	oauth       oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+	groupsClaim string
+}
+
+// NewOIDC builds a Provider from discovery metadata at
+// cfg.IssuerURL + "/.well-known/openid-configuration".
+func NewOIDC(ctx context.Context, cfg Config) (Provider, error) {
+	issuer, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery for %v failed: %v", cfg.Name, err)
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	groupsClaim := cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	return &oidcProvider{
+		name: cfg.Name,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint:     issuer.Endpoint(),
+		},
+		verifier:    issuer.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		groupsClaim: groupsClaim,
+	}, nil
+}
+
+func (p *oidcProvider) Name() string { return p.name }
+
+func (p *oidcProvider) AuthCodeURL(state, nonce string) string {
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(nonce))
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce string) (*Claims, error) {
+	token, err := p.oauth.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%v token exchange failed: %v", p.name, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%v: no id_token in token response", p.name)
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%v: id_token verification failed: %v", p.name, err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("%v: nonce mismatch", p.name)
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%v: failed to parse claims: %v", p.name, err)
+	}
+	groups, err := p.parseGroups(idToken)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", p.name, err)
+	}
+
+	return &Claims{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Groups:        groups,
+		RefreshToken:  token.RefreshToken,
+		ExpiresAt:     token.Expiry,
+	}, nil
+}
+
+// parseGroups pulls the configured groupsClaim out of idToken, tolerating
+// IdPs that omit it entirely.
+func (p *oidcProvider) parseGroups(idToken *oidc.IDToken) ([]string, error) {
+	var raw map[string]json.RawMessage
+	if err := idToken.Claims(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %v", err)
+	}
+	value, ok := raw[p.groupsClaim]
+	if !ok {
+		return nil, nil
+	}
+	var groups []string
+	if err := json.Unmarshal(value, &groups); err != nil {
+		return nil, fmt.Errorf("failed to parse %q claim: %v", p.groupsClaim, err)
+	}
+	return groups, nil
+}
+
+// Refresh exchanges a stored refresh token for a new access token and
+// re-verifies the resulting ID token.
+func (p *oidcProvider) Refresh(ctx context.Context, refreshToken string) (*Claims, error) {
+	src := p.oauth.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken})
+	token, err := src.Token()
+	if err != nil {
+		return nil, fmt.Errorf("%v: refresh failed: %v", p.name, err)
+	}
+	// Not every provider rotates the refresh token on use; keep the old
+	// one if a new one wasn't issued.
+	newRefreshToken := token.RefreshToken
+	if newRefreshToken == "" {
+		newRefreshToken = refreshToken
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%v: refresh response missing id_token", p.name)
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%v: refreshed id_token verification failed: %v", p.name, err)
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%v: failed to parse refreshed claims: %v", p.name, err)
+	}
+	groups, err := p.parseGroups(idToken)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", p.name, err)
+	}
+
+	return &Claims{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Groups:        groups,
+		RefreshToken:  newRefreshToken,
+		ExpiresAt:     token.Expiry,
+	}, nil
+}
@@ -0,0 +1,70 @@
+// Package providers implements pluggable OIDC/OAuth2 identity providers
+// used by the admin single sign-on login flow. Each Provider wraps the
+// provider-specific authorization endpoint, token exchange, and claim
+// mapping behind a common interface so handlers/oauth.go does not need
+// to know which backend is in use.
+package providers
+
+import (
+	"context"
+	"time"
+)
+
+// Claims is the normalized identity returned by a Provider after a
+// successful code exchange or refresh. Fields are mapped onto
+// handlers.JwtClaim by the caller.
+type Claims struct {
+	Subject string
+	Email   string
+	// EmailVerified reports whether the IdP attests that Email has been
+	// verified, e.g. the OIDC email_verified claim. Callers must not
+	// grant roles based on Email (domain allowlists in particular)
+	// unless this is true, since some IdPs let users set an arbitrary,
+	// unverified email on self-service accounts.
+	EmailVerified bool
+	Name          string
+	Groups        []string
+
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// Provider is implemented by every supported OIDC/OAuth2 backend.
+type Provider interface {
+	// Name is the lowercase identifier used in the OAUTH_PROVIDERS list
+	// and in the /oauth/<name>/login and /oauth/<name>/callback routes.
+	Name() string
+
+	// AuthCodeURL returns the URL to redirect the browser to in order to
+	// start the authorization code flow. state and nonce must be stored
+	// (e.g. in a short-lived cookie) and verified on callback.
+	AuthCodeURL(state, nonce string) string
+
+	// Exchange trades the authorization code returned on callback for an
+	// identity, verifying the ID token's signature, issuer, audience,
+	// expiry, and nonce where applicable.
+	Exchange(ctx context.Context, code, nonce string) (*Claims, error)
+
+	// Refresh rotates an expiring access token using a previously stored
+	// refresh token, returning the updated identity.
+	Refresh(ctx context.Context, refreshToken string) (*Claims, error)
+}
+
+// Config holds the per-provider settings read out of utils.Params.
+type Config struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+
+	// IssuerURL is the OIDC discovery issuer (https://.../.well-known/openid-configuration
+	// is derived from it). Only used by generic OIDC and providers backed by it.
+	IssuerURL string
+
+	// GroupsClaim is the name of the ID token claim holding the caller's
+	// group memberships, e.g. "groups" or "https://example.com/groups"
+	// for IdPs that namespace custom claims. Defaults to "groups" when
+	// empty. Only used by generic OIDC and providers backed by it.
+	GroupsClaim string
+}
@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/statping/statping/utils"
+)
+
+// presetIssuers holds the well-known OIDC discovery issuers for the
+// built-in providers that are plain OIDC under the hood. "github" is
+// handled separately since GitHub does not speak OIDC.
+var presetIssuers = map[string]string{
+	"google":    "https://accounts.google.com",
+	"gitlab":    "https://gitlab.com",
+	"microsoft": "https://login.microsoftonline.com/common/v2.0",
+}
+
+// Load reads the OAUTH_PROVIDERS setting (a comma-separated list of
+// provider names, e.g. "google,github,oidc") and returns a Provider for
+// each, configured from the OAUTH_<NAME>_* keys in utils.Params.
+func Load(ctx context.Context) (map[string]Provider, error) {
+	list := utils.Params.GetString("OAUTH_PROVIDERS")
+	if list == "" {
+		return nil, nil
+	}
+
+	out := make(map[string]Provider)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		cfg := configFor(name)
+		provider, err := build(ctx, name, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("oauth provider %v: %v", name, err)
+		}
+		out[name] = provider
+	}
+	return out, nil
+}
+
+func configFor(name string) Config {
+	prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+	cfg := Config{
+		Name:         name,
+		ClientID:     utils.Params.GetString(prefix + "CLIENT_ID"),
+		ClientSecret: utils.Params.GetString(prefix + "CLIENT_SECRET"),
+		RedirectURL:  utils.Params.GetString(prefix + "REDIRECT_URL"),
+		IssuerURL:    utils.Params.GetString(prefix + "ISSUER_URL"),
+		GroupsClaim:  utils.Params.GetString(prefix + "GROUPS_CLAIM"),
+	}
+	if scopes := utils.Params.GetString(prefix + "SCOPES"); scopes != "" {
+		cfg.Scopes = strings.Split(scopes, ",")
+	}
+	if cfg.IssuerURL == "" {
+		cfg.IssuerURL = presetIssuers[name]
+	}
+	return cfg
+}
+
+func build(ctx context.Context, name string, cfg Config) (Provider, error) {
+	switch name {
+	case "github":
+		return NewGitHub(cfg), nil
+	case "google", "gitlab", "microsoft", "oidc":
+		if cfg.IssuerURL == "" {
+			return nil, fmt.Errorf("missing OAUTH_%v_ISSUER_URL", strings.ToUpper(name))
+		}
+		return NewOIDC(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+}
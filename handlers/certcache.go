@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awssession "github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/statping/statping/database"
+)
+
+// dbCertCache implements autocert.Cache on top of the existing Statping
+// database so every replica behind a load balancer shares the same
+// certificates instead of each solving its own ACME challenge.
+type dbCertCache struct{}
+
+func newDBCertCache() autocert.Cache {
+	return dbCertCache{}
+}
+
+type certRow struct {
+	Key  string `gorm:"primary_key;column:key" json:"-"`
+	Data []byte `gorm:"column:data" json:"-"`
+}
+
+func (certRow) TableName() string {
+	return "core_certificates"
+}
+
+func certTable() database.Database {
+	return database.Database(&certRow{})
+}
+
+func (dbCertCache) Get(ctx context.Context, name string) ([]byte, error) {
+	var row certRow
+	q := certTable().Where("key = ?", name).Find(&row)
+	if q.Error() != nil {
+		return nil, q.Error()
+	}
+	if row.Key == "" {
+		return nil, autocert.ErrCacheMiss
+	}
+	return row.Data, nil
+}
+
+func (dbCertCache) Put(ctx context.Context, name string, data []byte) error {
+	return certTable().Save(&certRow{Key: name, Data: data}).Error()
+}
+
+func (dbCertCache) Delete(ctx context.Context, name string) error {
+	return certTable().Where("key = ?", name).Delete(&certRow{}).Error()
+}
+
+// s3CertCache implements autocert.Cache by storing each cache entry as
+// an object under prefix in an S3-compatible bucket, configured via
+// standard AWS credentials/region environment variables.
+type s3CertCache struct {
+	bucket string
+	prefix string
+	client *s3.S3
+}
+
+func newS3CertCache(bucketAndPrefix string) (autocert.Cache, error) {
+	parts := strings.SplitN(bucketAndPrefix, "/", 2)
+	bucket := parts[0]
+	if bucket == "" {
+		return nil, fmt.Errorf("LETSENCRYPT_CACHE=s3:// requires a bucket name")
+	}
+	prefix := ""
+	if len(parts) == 2 {
+		prefix = strings.TrimSuffix(parts[1], "/")
+	}
+
+	sess, err := awssession.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 session: %v", err)
+	}
+
+	return &s3CertCache{
+		bucket: bucket,
+		prefix: prefix,
+		client: s3.New(sess),
+	}, nil
+}
+
+func (c *s3CertCache) key(name string) string {
+	if c.prefix == "" {
+		return name
+	}
+	return c.prefix + "/" + name
+}
+
+func (c *s3CertCache) Get(ctx context.Context, name string) ([]byte, error) {
+	out, err := c.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.key(name)),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+	defer out.Body.Close()
+	return ioutil.ReadAll(out.Body)
+}
+
+func (c *s3CertCache) Put(ctx context.Context, name string, data []byte) error {
+	_, err := c.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.key(name)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (c *s3CertCache) Delete(ctx context.Context, name string) error {
+	_, err := c.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.key(name)),
+	})
+	return err
+}
+
+func isS3NotFound(err error) bool {
+	return strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "NotFound")
+}
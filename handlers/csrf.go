@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+const csrfHeader = "X-CSRF-Token"
+const csrfFormField = "csrf_token"
+
+// csrfSecret derives the key used to sign CSRF tokens from jwtKey so it
+// survives restarts without a separate secret to provision. It is a
+// double-submit token: a per-session HMAC the client must echo back on
+// every state-changing request, not a capability secret on its own.
+func csrfSecret() []byte {
+	return jwtKey
+}
+
+// csrfTokenForSession returns the CSRF token for the session attached to
+// r, or "" if sessionIdentifier finds none. In the default cookie mode
+// any non-empty statping_auth cookie yields a token, whether or not it
+// names a live session. This is exposed to templates as {{ csrfToken }}.
+func csrfTokenForSession(r *http.Request) string {
+	id := sessionIdentifier(r)
+	if id == "" {
+		return ""
+	}
+	return signCSRFToken(id)
+}
+
+func signCSRFToken(sessionID string) string {
+	mac := hmac.New(sha256.New, csrfSecret())
+	mac.Write([]byte(sessionID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkCSRF reports whether r is allowed through: GET/HEAD/OPTIONS always
+// pass, as does anything authenticated by API key or Authorization:
+// Bearer header (not susceptible to cross-site form/ajax forgery), and a
+// request with no statping_auth session has nothing a forged cross-site
+// request could ride on either. Everything else must carry a valid
+// double-submit CSRF token in the X-CSRF-Token header or csrf_token form
+// field.
+func checkCSRF(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		return true
+	}
+	if hasAPIQuery(r) || hasAuthorizationHeader(r) {
+		return true
+	}
+	id := sessionIdentifier(r)
+	if id == "" {
+		return true
+	}
+
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.FormValue(csrfFormField)
+	}
+	expected := signCSRFToken(id)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+func rejectCSRF(w http.ResponseWriter) {
+	http.Error(w, "invalid or missing CSRF token", http.StatusForbidden)
+}
+
+// csrfProtect wraps a single route with the CSRF check. Use this to
+// adopt CSRF protection one route at a time: once a route's template (or
+// the SPA view backing it) is sending {{ csrfToken }} back on its writes
+// via X-CSRF-Token, wrap that route's handler with csrfProtect in
+// Router(). This is the migration path toward csrfMiddleware below —
+// there is no base.gohtml/frontend change in this series yet, so nothing
+// is wrapped with it, but new and updated mutating routes should adopt
+// it as their views gain the token instead of waiting for every route to
+// be ready at once.
+func csrfProtect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !checkCSRF(r) {
+			rejectCSRF(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// csrfMiddleware is the router-wide equivalent of csrfProtect, applying
+// the same check to every route in one pass. Only register this with
+// router.Use once every cookie-authenticated mutating route has been
+// migrated to send the token (see csrfProtect) — applying it before then
+// 403s any route whose view hasn't caught up yet.
+func csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkCSRF(r) {
+			rejectCSRF(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
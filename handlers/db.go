@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/statping/statping/database"
+)
+
+// sessionRow is the database-backed representation of a session, used when
+// SESSION_STORE=database so every Statping replica behind a load
+// balancer shares the same sessions instead of each holding its own
+// in-memory copy.
+type sessionRow struct {
+	Id           string    `gorm:"primary_key;column:id" json:"-"`
+	Subject      string    `gorm:"column:subject" json:"-"`
+	Email        string    `gorm:"column:email" json:"-"`
+	Admin        bool      `gorm:"column:admin" json:"-"`
+	IssuedAt     int64     `gorm:"column:issued_at" json:"-"`
+	Provider     string    `gorm:"column:provider" json:"-"`
+	RefreshToken string    `gorm:"column:refresh_token" json:"-"`
+	TokenExpires int64     `gorm:"column:token_expires" json:"-"`
+	ExpiresAt    time.Time `gorm:"column:expires_at" json:"-"`
+}
+
+func (sessionRow) TableName() string {
+	return "core_sessions"
+}
+
+func sessionsTable() database.Database {
+	return database.Database(&sessionRow{})
+}
+
+func upsertSessionRow(id string, claim JwtClaim, expiresAt time.Time) error {
+	row := sessionRow{
+		Id:           id,
+		Subject:      claim.Id,
+		Email:        claim.Email,
+		Admin:        claim.Admin,
+		IssuedAt:     claim.IssuedAt,
+		Provider:     claim.Provider,
+		RefreshToken: claim.RefreshToken,
+		TokenExpires: claim.ExpiresAt,
+		ExpiresAt:    expiresAt,
+	}
+	return sessionsTable().Save(&row).Error()
+}
+
+func selectSessionRow(id string) (JwtClaim, bool, error) {
+	var row sessionRow
+	q := sessionsTable().Where("id = ?", id).Find(&row)
+	if q.Error() != nil {
+		return JwtClaim{}, false, q.Error()
+	}
+	if row.Id == "" || time.Now().After(row.ExpiresAt) {
+		return JwtClaim{}, false, nil
+	}
+	return JwtClaim{
+		Id:           row.Subject,
+		Email:        row.Email,
+		Admin:        row.Admin,
+		IssuedAt:     row.IssuedAt,
+		Provider:     row.Provider,
+		RefreshToken: row.RefreshToken,
+		ExpiresAt:    row.TokenExpires,
+	}, true, nil
+}
+
+func selectSessionRowExpiry(id string) (time.Time, bool, error) {
+	var row sessionRow
+	q := sessionsTable().Where("id = ?", id).Find(&row)
+	if q.Error() != nil {
+		return time.Time{}, false, q.Error()
+	}
+	if row.Id == "" {
+		return time.Time{}, false, nil
+	}
+	return row.ExpiresAt, true, nil
+}
+
+func deleteSessionRow(id string) error {
+	return sessionsTable().Where("id = ?", id).Delete(&sessionRow{}).Error()
+}
+
+func expireAllSessionRows() error {
+	return sessionsTable().Where("expires_at < ?", time.Now()).Delete(&sessionRow{}).Error()
+}
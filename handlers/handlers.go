@@ -1,11 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
-	"path"
 	"time"
 
 	"github.com/statping/statping/source"
@@ -32,6 +32,105 @@ func StopHTTPServer(err error) {
 	log.Infoln("Stopping HTTP Server")
 }
 
+// JwtClaim is the identity carried by a session, whether it originated
+// from the local login form or an OAuth/OIDC provider.
+type JwtClaim struct {
+	Id       string
+	Email    string
+	Admin    bool
+	IssuedAt int64
+
+	// Provider-backed sessions carry enough to silently refresh the
+	// access token server-side; these never leave the session store.
+	Provider     string
+	RefreshToken string
+	ExpiresAt    int64
+}
+
+// Valid only checks shape here: in the default mode expiry is enforced
+// by sessionStore.Get against the store's own (refresh-extended)
+// expiresAt, and in split-cookie mode it's enforced by
+// decodeClientClaim against IssuedAt before a claim ever reaches this
+// point.
+func (c JwtClaim) Valid() error {
+	if c.Id == "" {
+		return fmt.Errorf("empty subject")
+	}
+	return nil
+}
+
+// setJwtToken starts (or restarts) a session for claim. In the default
+// mode this stores claim server-side and writes only its opaque session
+// ID to the browser; in split-cookie mode the signed claim itself is
+// written to the browser, sharded across statping_auth_N cookies.
+func setJwtToken(w http.ResponseWriter, claim JwtClaim) error {
+	if splitCookiesEnabled() {
+		token, err := encodeClientClaim(claim)
+		if err != nil {
+			return err
+		}
+		return writeSessionCookie(w, token)
+	}
+
+	id, err := sessionStore.Create(claim, sessionTTL)
+	if err != nil {
+		return err
+	}
+	return writeSessionCookie(w, id)
+}
+
+// getJwtToken resolves the session attached to r. In the default mode
+// it looks the claim up in sessionStore and transparently rotates the
+// provider access token when it is within refreshWindow of expiring,
+// extending the session's expiry along with it. In split-cookie mode
+// the claim is read straight out of the signed cookie; because there is
+// no server-side record to update, proactive refresh is skipped there
+// and the claim is re-minted on the next /oauth/<provider>/login
+// instead.
+func getJwtToken(r *http.Request) (JwtClaim, error) {
+	if splitCookiesEnabled() {
+		value := readSessionCookie(r)
+		if value == "" {
+			return JwtClaim{}, fmt.Errorf("no session cookie")
+		}
+		return decodeClientClaim(value)
+	}
+
+	id := readSessionCookie(r)
+	if id == "" {
+		return JwtClaim{}, fmt.Errorf("no session cookie")
+	}
+	claim, ok, err := sessionStore.Get(id)
+	if err != nil || !ok {
+		return JwtClaim{}, fmt.Errorf("session not found")
+	}
+
+	if claim.RefreshToken != "" && claim.ExpiresAt > 0 {
+		expires := time.Unix(claim.ExpiresAt, 0)
+		if time.Until(expires) < refreshWindow {
+			refreshed, err := refreshProviderSession(context.Background(), claim)
+			if err != nil {
+				log.Errorln("session refresh failed: " + err.Error())
+				return claim, nil
+			}
+			claim = refreshed
+			_ = sessionStore.Update(id, claim, sessionTTL)
+		}
+	}
+
+	return claim, nil
+}
+
+// resetCookies is called once on server startup and deletes session rows
+// whose expiry has already passed. Unexpired rows are kept, so
+// database-backed sessions outlive a restart; the in-memory store starts
+// empty anyway.
+func resetCookies() {
+	if err := expireAllSessionRows(); err != nil {
+		log.Errorln(err)
+	}
+}
+
 // RunHTTPServer will start a HTTP server on a specific IP and port
 func RunHTTPServer() error {
 	if utils.Params.GetBool("DISABLE_HTTP") {
@@ -51,7 +150,10 @@ func RunHTTPServer() error {
 		log.Infoln("Statping HTTP Server running on http://" + host + basePath)
 	}
 
+	loadOAuthProviders()
+
 	router = Router()
+	oauthRoutes(router)
 	resetCookies()
 
 	if utils.Params.GetBool("LETSENCRYPT_ENABLE") {
@@ -148,6 +250,9 @@ func loadTemplate(w http.ResponseWriter, r *http.Request) (*template.Template, e
 		return nil, err
 	}
 	mainTemplate.Funcs(handlerFuncs(w, r))
+	mainTemplate.Funcs(template.FuncMap{
+		"csrfToken": func() string { return csrfTokenForSession(r) },
+	})
 	// render all templates
 	for _, temp := range templates {
 		tmp, _ := source.TmplBox.String(temp)
@@ -163,7 +268,11 @@ func loadTemplate(w http.ResponseWriter, r *http.Request) (*template.Template, e
 // ExecuteResponse will render a HTTP response for the front end user
 func ExecuteResponse(w http.ResponseWriter, r *http.Request, file string, data interface{}, redirect interface{}) {
 	if url, ok := redirect.(string); ok {
-		http.Redirect(w, r, path.Join(basePath, url), http.StatusSeeOther)
+		if !IsValidRedirect(url) {
+			log.Errorln(fmt.Sprintf("rejected redirect to untrusted target %q", url))
+			url = basePath
+		}
+		http.Redirect(w, r, safeRedirectPath(url), http.StatusSeeOther)
 		return
 	}
 	if usingSSL {
@@ -191,16 +300,50 @@ func returnJson(d interface{}, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if e, ok := d.(errors.Error); ok {
 		w.WriteHeader(e.Status())
-		json.NewEncoder(w).Encode(e)
+		writeJsonRedirect(w, r, e)
 		return
 	}
 	if e, ok := d.(error); ok {
 		w.WriteHeader(500)
-		json.NewEncoder(w).Encode(errors.New(e.Error()))
+		writeJsonRedirect(w, r, errors.New(e.Error()))
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(d)
+	writeJsonRedirect(w, r, d)
+}
+
+// writeJsonRedirect encodes d as JSON, adding a "redirect" field sourced
+// from the request's ?redirect= query parameter when one is present. The
+// target is validated with IsValidRedirect and normalized with
+// safeRedirectPath exactly like ExecuteResponse, so a crafted ?redirect=
+// on a JSON endpoint can't turn its error response into an open redirect
+// for a caller that follows it.
+func writeJsonRedirect(w http.ResponseWriter, r *http.Request, d interface{}) {
+	target := r.URL.Query().Get("redirect")
+	if target == "" {
+		json.NewEncoder(w).Encode(d)
+		return
+	}
+	if !IsValidRedirect(target) {
+		log.Errorln(fmt.Sprintf("rejected redirect to untrusted target %q", target))
+		json.NewEncoder(w).Encode(d)
+		return
+	}
+
+	payload, err := json.Marshal(d)
+	if err != nil {
+		json.NewEncoder(w).Encode(d)
+		return
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		// d didn't marshal to a JSON object (e.g. a scalar or array), so
+		// there's nowhere to attach "redirect" -- fall back to it as-is.
+		w.Write(payload)
+		return
+	}
+	fields["redirect"] = safeRedirectPath(target)
+	json.NewEncoder(w).Encode(fields)
 }
 
 func returnLastResponse(s *services.Service, w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/statping/statping/utils"
+)
+
+// letsencryptRenewals counts every certificate written to the autocert
+// cache, i.e. every initial issuance and renewal, regardless of which
+// Cache backend is in use.
+var letsencryptRenewals = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "statping_letsencrypt_renewals_total",
+	Help: "Number of Let's Encrypt certificates issued or renewed",
+})
+
+func init() {
+	prometheus.MustRegister(letsencryptRenewals)
+}
+
+// startLetsEncryptServer serves HTTPS using a certificate obtained and
+// renewed automatically via ACME/Let's Encrypt. The certificate cache is
+// pluggable (LETSENCRYPT_CACHE) so replicas behind a load balancer can
+// share certificates instead of each solving its own ACME challenge.
+func startLetsEncryptServer(ip string) error {
+	hosts := letsEncryptHosts()
+	if len(hosts) == 0 {
+		return fmt.Errorf("LETSENCRYPT_ENABLE is set but LETSENCRYPT_HOSTS is empty")
+	}
+
+	cache, err := letsEncryptCache()
+	if err != nil {
+		return err
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Cache:      renewalCountingCache{cache},
+	}
+
+	go startOCSPStapleRefresher(manager)
+
+	httpServer = &http.Server{
+		Addr: fmt.Sprintf("%v:443", ip),
+		TLSConfig: &tls.Config{
+			// GetCertificate is called per-handshake, so a renewed
+			// certificate (and, separately, a refreshed OCSP staple) is
+			// picked up on the very next connection with no server
+			// restart and no dropped connections.
+			GetCertificate: stapledGetCertificate(manager),
+		},
+		Handler:      router,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+	}
+
+	// The ACME http-01 challenge must be served on :80.
+	go func() {
+		addr := fmt.Sprintf("%v:80", ip)
+		if err := http.ListenAndServe(addr, manager.HTTPHandler(nil)); err != nil {
+			log.Errorln(fmt.Sprintf("Let's Encrypt HTTP-01 challenge server on %v failed: %v", addr, err))
+		}
+	}()
+
+	log.Infoln(fmt.Sprintf("Statping HTTPS Server (Let's Encrypt) running for %v", strings.Join(hosts, ", ")))
+	return httpServer.ListenAndServeTLS("", "")
+}
+
+func letsEncryptHosts() []string {
+	raw := utils.Params.GetString("LETSENCRYPT_HOSTS")
+	if raw == "" {
+		return nil
+	}
+	var hosts []string
+	for _, h := range strings.Split(raw, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// letsEncryptCache builds the autocert.Cache backend selected by
+// LETSENCRYPT_CACHE: a bare directory path (default utils.Directory + "/certs"), "s3://bucket/prefix",
+// or "database" to reuse the existing Statping database.
+func letsEncryptCache() (autocert.Cache, error) {
+	setting := utils.Params.GetString("LETSENCRYPT_CACHE")
+	switch {
+	case setting == "":
+		return autocert.DirCache(utils.Directory + "/certs"), nil
+	case setting == "database":
+		return newDBCertCache(), nil
+	case strings.HasPrefix(setting, "s3://"):
+		return newS3CertCache(strings.TrimPrefix(setting, "s3://"))
+	default:
+		return autocert.DirCache(setting), nil
+	}
+}
+
+// renewalCountingCache wraps an autocert.Cache purely to observe writes
+// for the Prometheus renewal counter.
+type renewalCountingCache struct {
+	autocert.Cache
+}
+
+func (c renewalCountingCache) Put(ctx context.Context, name string, data []byte) error {
+	if err := c.Cache.Put(ctx, name, data); err != nil {
+		return err
+	}
+	// autocert also stores its ACME account key and http-01 challenge
+	// tokens in the cache; only certificate entries are counted.
+	if name == "acme_account+key" || strings.HasSuffix(name, "+http-01") {
+		return nil
+	}
+	letsencryptRenewals.Inc()
+	return nil
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/statping/statping/handlers/auth/providers"
+	"github.com/statping/statping/utils"
+)
+
+const (
+	oauthStateCookie    = "statping_oauth_state"
+	oauthNonceCookie    = "statping_oauth_nonce"
+	oauthRedirectCookie = "statping_oauth_redirect"
+)
+
+var oauthProviders map[string]providers.Provider
+
+// loadOAuthProviders populates oauthProviders from the OAUTH_PROVIDERS
+// setting. It is called once from RunHTTPServer; a nil/empty map simply
+// means SSO is not configured and the oauth routes 404.
+func loadOAuthProviders() {
+	loaded, err := providers.Load(context.Background())
+	if err != nil {
+		log.Errorln("oauth: " + err.Error())
+		return
+	}
+	oauthProviders = loaded
+}
+
+// oauthRoutes mounts /oauth/<provider>/login and /oauth/<provider>/callback
+// for every configured provider. Called from RunHTTPServer on the router
+// returned by Router().
+func oauthRoutes(r *mux.Router) {
+	r.HandleFunc("/oauth/{provider}/login", oauthLoginHandler).Methods(http.MethodGet)
+	r.HandleFunc("/oauth/{provider}/callback", oauthCallbackHandler).Methods(http.MethodGet)
+}
+
+func oauthProvider(r *http.Request) (providers.Provider, bool) {
+	name := mux.Vars(r)["provider"]
+	p, ok := oauthProviders[name]
+	return p, ok
+}
+
+func oauthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oauthProvider(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	state := utils.NewUUID()
+	nonce := utils.NewUUID()
+	setShortLivedCookie(w, oauthStateCookie, state)
+	setShortLivedCookie(w, oauthNonceCookie, nonce)
+	if target := r.URL.Query().Get("redirect"); IsValidRedirect(target) {
+		setShortLivedCookie(w, oauthRedirectCookie, target)
+	}
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, nonce), http.StatusSeeOther)
+}
+
+func oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oauthProvider(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	stateCookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || stateCookie.Value == "" || stateCookie.Value != r.URL.Query().Get("state") {
+		http.Error(w, "invalid oauth state", http.StatusBadRequest)
+		return
+	}
+	nonceCookie, err := r.Cookie(oauthNonceCookie)
+	if err != nil {
+		http.Error(w, "invalid oauth state", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), nonceCookie.Value)
+	if err != nil {
+		log.Errorln(err)
+		http.Error(w, "oauth sign-in failed", http.StatusUnauthorized)
+		return
+	}
+
+	jwtClaim := JwtClaim{
+		Id:           claims.Subject,
+		Email:        claims.Email,
+		Admin:        isOAuthAdmin(claims),
+		IssuedAt:     time.Now().Unix(),
+		Provider:     provider.Name(),
+		RefreshToken: claims.RefreshToken,
+		ExpiresAt:    claims.ExpiresAt.Unix(),
+	}
+	if err := setJwtToken(w, jwtClaim); err != nil {
+		log.Errorln(err)
+		http.Error(w, "oauth sign-in failed", http.StatusInternalServerError)
+		return
+	}
+
+	redirect := basePath
+	if cookie, err := r.Cookie(oauthRedirectCookie); err == nil && IsValidRedirect(cookie.Value) {
+		redirect = safeRedirectPath(cookie.Value)
+	}
+	clearShortLivedCookie(w, oauthRedirectCookie)
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// isOAuthAdmin grants the admin role when the claim's email domain or one
+// of its groups matches the configured allowlists. Domain matching is
+// only trusted when the IdP attests the email is verified; several
+// generic/self-service OIDC providers let a user set an arbitrary,
+// unverified email, which would otherwise let anyone claim
+// "anyone@<allowed-domain>" and get promoted to admin.
+func isOAuthAdmin(claims *providers.Claims) bool {
+	for _, domain := range strings.Split(utils.Params.GetString("OAUTH_ADMIN_DOMAINS"), ",") {
+		domain = strings.TrimSpace(domain)
+		if domain != "" && claims.EmailVerified && strings.HasSuffix(claims.Email, "@"+domain) {
+			return true
+		}
+	}
+	adminGroups := strings.Split(utils.Params.GetString("OAUTH_ADMIN_GROUPS"), ",")
+	for _, group := range claims.Groups {
+		for _, allowed := range adminGroups {
+			if group == strings.TrimSpace(allowed) && allowed != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func setShortLivedCookie(w http.ResponseWriter, name, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     basePath,
+		HttpOnly: true,
+		Secure:   usingSSL,
+		MaxAge:   300,
+	})
+}
+
+func clearShortLivedCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     basePath,
+		HttpOnly: true,
+		Secure:   usingSSL,
+		MaxAge:   -1,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspRefreshInterval controls how often cached leaf certificates are
+// re-checked against their issuer's OCSP responder so a stapled response
+// never goes stale between renewals.
+const ocspRefreshInterval = 12 * time.Hour
+
+// ocspStaples holds the most recently fetched DER-encoded OCSP response
+// per host, attached to outgoing certificates by stapledGetCertificate.
+var ocspStaples = struct {
+	mu     sync.RWMutex
+	byHost map[string][]byte
+}{byHost: make(map[string][]byte)}
+
+// stapledGetCertificate wraps manager.GetCertificate so every handshake
+// serves the certificate with its most recently fetched OCSP staple
+// attached, without needing to touch httpServer.TLSConfig again when the
+// staple is refreshed.
+func stapledGetCertificate(manager *autocert.Manager) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := manager.GetCertificate(hello)
+		if err != nil {
+			return nil, err
+		}
+		ocspStaples.mu.RLock()
+		staple := ocspStaples.byHost[hello.ServerName]
+		ocspStaples.mu.RUnlock()
+		if staple != nil {
+			cert.OCSPResponse = staple
+		}
+		return cert, nil
+	}
+}
+
+// startOCSPStapleRefresher periodically walks the hosts in
+// LETSENCRYPT_HOSTS (manager.GetCertificate obtains a certificate for a
+// host that has none cached yet), fetches a fresh OCSP response from
+// each one's issuer, and stores it for stapledGetCertificate to attach
+// on the next handshake. Responder failures are logged, not fatal:
+// stapling is a hardening measure, not a prerequisite for serving
+// traffic.
+func startOCSPStapleRefresher(manager *autocert.Manager) {
+	refreshOCSPStaples(manager)
+	ticker := time.NewTicker(ocspRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		refreshOCSPStaples(manager)
+	}
+}
+
+func refreshOCSPStaples(manager *autocert.Manager) {
+	for _, host := range letsEncryptHosts() {
+		cert, err := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: host})
+		if err != nil || cert == nil || len(cert.Certificate) < 2 {
+			continue
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			continue
+		}
+		issuer, err := x509.ParseCertificate(cert.Certificate[1])
+		if err != nil {
+			continue
+		}
+		staple, err := fetchOCSPResponse(leaf, issuer)
+		if err != nil {
+			log.Errorln("ocsp staple refresh for " + host + " failed: " + err.Error())
+			continue
+		}
+		ocspStaples.mu.Lock()
+		ocspStaples.byHost[host] = staple
+		ocspStaples.mu.Unlock()
+	}
+}
+
+// fetchOCSPResponse builds an OCSP request for leaf/issuer, POSTs it to
+// leaf's OCSP responder, and returns the raw DER response once it's been
+// validated against issuer.
+func fetchOCSPResponse(leaf, issuer *x509.Certificate) ([]byte, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, fmt.Errorf("certificate has no OCSP responder URL")
+	}
+
+	reqBytes, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("ocsp responder request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ocsp.ParseResponseForCert(respBytes, leaf, issuer); err != nil {
+		return nil, fmt.Errorf("ocsp response invalid: %v", err)
+	}
+
+	return respBytes, nil
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/statping/statping/utils"
+)
+
+func TestIsValidRedirect(t *testing.T) {
+	utils.Params.Set("ALLOWED_REDIRECT_DOMAINS", "example.com,.trusted.example.com")
+
+	tests := []struct {
+		name   string
+		target string
+		want   bool
+	}{
+		{"relative path", "/dashboard", true},
+		{"relative path with traversal", "/../admin", true},
+		{"allowed host", "https://example.com/dashboard", true},
+		{"allowed subdomain wildcard", "https://app.trusted.example.com/dashboard", true},
+		{"protocol-relative", "//evil.com/phish", false},
+		{"backslash-slash protocol-relative", "/\\evil.com/phish", false},
+		{"slash-backslash protocol-relative", "\\/evil.com/phish", false},
+		{"double-backslash protocol-relative", "\\\\evil.com/phish", false},
+		{"mismatched suffix", "https://evil-example.com/phish", false},
+		{"unrelated host", "https://attacker.test/phish", false},
+		{"empty target", "", false},
+		{"encoded traversal", "/%2e%2e/%2e%2e/admin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidRedirect(tt.target); got != tt.want {
+				t.Errorf("IsValidRedirect(%q) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeRedirectPath(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/dashboard", "/dashboard"},
+		{"../../etc/passwd", "/etc/passwd"},
+		{"/a/../../b", "/b"},
+		{"/%2e%2e/%2e%2e/admin", "/admin"},
+		{"https://app.trusted.example.com/a/../../admin", "https://app.trusted.example.com/admin"},
+	}
+
+	for _, tt := range tests {
+		if got := safeRedirectPath(tt.target); got != tt.want {
+			t.Errorf("safeRedirectPath(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+)
+
+// refreshProviderSession rotates claim's access token using its stored
+// refresh token against the originating provider's token endpoint.
+func refreshProviderSession(ctx context.Context, claim JwtClaim) (JwtClaim, error) {
+	provider, ok := oauthProviders[claim.Provider]
+	if !ok {
+		return claim, fmt.Errorf("provider %v is no longer configured", claim.Provider)
+	}
+	refreshed, err := provider.Refresh(ctx, claim.RefreshToken)
+	if err != nil {
+		return claim, err
+	}
+	claim.Admin = isOAuthAdmin(refreshed)
+	claim.Email = refreshed.Email
+	claim.RefreshToken = refreshed.RefreshToken
+	claim.ExpiresAt = refreshed.ExpiresAt.Unix()
+	return claim, nil
+}
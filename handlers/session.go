@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/statping/statping/utils"
+)
+
+// refreshWindow is how far ahead of expiry a session is proactively
+// refreshed using its stored refresh token.
+const refreshWindow = 60 * time.Second
+
+// sessionTTL is how long a session lasts, whether that means the
+// server-side record in sessionStore or, in split-cookie mode, the
+// IssuedAt window a signed client-side claim is trusted for. Every
+// successful proactive refresh extends it from the current time.
+const sessionTTL = timeout * 48
+
+// maxCookieShards caps how many statping_auth_N cookies a split session
+// can be spread across before it is rejected outright.
+const maxCookieShards = 8
+
+// session is what's kept server-side, keyed by an opaque session ID.
+type session struct {
+	claim     JwtClaim
+	expiresAt time.Time
+}
+
+// SessionStore persists sessions out-of-process so a JWT (and any
+// provider refresh token) never has to round-trip to the browser.
+type SessionStore interface {
+	Create(claim JwtClaim, ttl time.Duration) (id string, err error)
+	Get(id string) (JwtClaim, bool, error)
+	Update(id string, claim JwtClaim, ttl time.Duration) error
+	Delete(id string) error
+}
+
+var sessionStore SessionStore = newMemorySessionStore()
+
+func init() {
+	if utils.Params.GetString("SESSION_STORE") == "database" {
+		sessionStore = newDBSessionStore()
+	}
+}
+
+// memorySessionStore is the default backend; sessions do not survive a
+// restart, which matches the previous single-process JWT-cookie behavior.
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]session)}
+}
+
+func (m *memorySessionStore) Create(claim JwtClaim, ttl time.Duration) (string, error) {
+	id := utils.NewUUID()
+	m.mu.Lock()
+	m.sessions[id] = session{claim: claim, expiresAt: time.Now().Add(ttl)}
+	m.mu.Unlock()
+	return id, nil
+}
+
+func (m *memorySessionStore) Get(id string) (JwtClaim, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok || time.Now().After(s.expiresAt) {
+		delete(m.sessions, id)
+		return JwtClaim{}, false, nil
+	}
+	return s.claim, true, nil
+}
+
+func (m *memorySessionStore) Update(id string, claim JwtClaim, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.sessions[id]
+	if !ok {
+		return fmt.Errorf("session %v not found", id)
+	}
+	m.sessions[id] = session{claim: claim, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *memorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// dbSessionStore persists sessions in the existing Statping database so
+// every replica behind a load balancer shares the same session table.
+type dbSessionStore struct{}
+
+func newDBSessionStore() *dbSessionStore {
+	return &dbSessionStore{}
+}
+
+func (d *dbSessionStore) Create(claim JwtClaim, ttl time.Duration) (string, error) {
+	id := utils.NewUUID()
+	if err := upsertSessionRow(id, claim, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (d *dbSessionStore) Get(id string) (JwtClaim, bool, error) {
+	return selectSessionRow(id)
+}
+
+func (d *dbSessionStore) Update(id string, claim JwtClaim, ttl time.Duration) error {
+	if _, ok, err := selectSessionRowExpiry(id); err != nil || !ok {
+		return fmt.Errorf("session %v not found", id)
+	}
+	return upsertSessionRow(id, claim, time.Now().Add(ttl))
+}
+
+func (d *dbSessionStore) Delete(id string) error {
+	return deleteSessionRow(id)
+}
+
+// splitCookiesEnabled selects between the two cookie modes. The default
+// keeps only an opaque session ID client-side (small enough it never
+// needs sharding) with the claim and any provider refresh token held in
+// sessionStore. SESSION_SPLIT_COOKIES instead serializes and signs the
+// whole claim into the cookie itself, sharded across statping_auth_N
+// cookies once it grows past a single cookie's size budget — for
+// deployments that can't provision a shared session store (no database,
+// no sticky load balancing) but still need SSO claims too large for one
+// cookie.
+func splitCookiesEnabled() bool {
+	return utils.Params.GetBool("SESSION_SPLIT_COOKIES")
+}
+
+// clientClaim is the subset of JwtClaim that is safe to hand to the
+// browser in split-cookie mode. It deliberately omits Provider,
+// RefreshToken and ExpiresAt: those exist so getJwtToken can silently
+// refresh a provider-backed session server-side, split-cookie mode has
+// no server-side record to refresh anyway (see getJwtToken), and a
+// refresh token is a long-lived credential that must never leave the
+// server.
+type clientClaim struct {
+	Id       string
+	Email    string
+	Admin    bool
+	IssuedAt int64
+}
+
+// encodeClientClaim serializes the client-safe subset of claim and signs
+// it with an HMAC keyed on jwtKey, so a client-side cookie can't be
+// forged or tampered with despite never touching the session store.
+func encodeClientClaim(claim JwtClaim) (string, error) {
+	payload, err := json.Marshal(clientClaim{
+		Id:       claim.Id,
+		Email:    claim.Email,
+		Admin:    claim.Admin,
+		IssuedAt: claim.IssuedAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + signClientClaim(encodedPayload), nil
+}
+
+// decodeClientClaim reverses encodeClientClaim, rejecting anything whose
+// signature doesn't match or whose IssuedAt is older than sessionTTL.
+// Unlike the default mode, a split-cookie session has no server-side
+// record to expire it, so the age limit has to be enforced here instead
+// or a leaked cookie would stay valid forever.
+func decodeClientClaim(token string) (JwtClaim, error) {
+	dot := strings.LastIndexByte(token, '.')
+	if dot < 0 {
+		return JwtClaim{}, fmt.Errorf("malformed session cookie")
+	}
+	encodedPayload, signature := token[:dot], token[dot+1:]
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(signClientClaim(encodedPayload))) != 1 {
+		return JwtClaim{}, fmt.Errorf("session cookie signature mismatch")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return JwtClaim{}, fmt.Errorf("malformed session cookie")
+	}
+	var claim clientClaim
+	if err := json.Unmarshal(payload, &claim); err != nil {
+		return JwtClaim{}, fmt.Errorf("malformed session cookie")
+	}
+	if time.Since(time.Unix(claim.IssuedAt, 0)) > sessionTTL {
+		return JwtClaim{}, fmt.Errorf("session cookie expired")
+	}
+	return JwtClaim{
+		Id:       claim.Id,
+		Email:    claim.Email,
+		Admin:    claim.Admin,
+		IssuedAt: claim.IssuedAt,
+	}, nil
+}
+
+func signClientClaim(encodedPayload string) string {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte(encodedPayload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cookieShardSize is kept comfortably under the ~4096 byte limit most
+// browsers enforce per cookie.
+const cookieShardSize = 3800
+
+// writeSessionCookie writes value to the browser, sharded across
+// statping_auth_N cookies if it doesn't fit in one. It returns an error
+// instead of silently truncating when value needs more than
+// maxCookieShards cookies, since a truncated signed payload fails
+// signature verification on the very next request and would otherwise
+// log the user straight back out with nothing to explain why.
+func writeSessionCookie(w http.ResponseWriter, value string) error {
+	if len(value) <= cookieShardSize {
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    value,
+			Path:     basePath,
+			HttpOnly: true,
+			Secure:   usingSSL,
+		})
+		return nil
+	}
+
+	shards := (len(value) + cookieShardSize - 1) / cookieShardSize
+	if shards > maxCookieShards {
+		return fmt.Errorf("session too large for %v cookies (%v shards needed)", maxCookieShards, shards)
+	}
+
+	for i := 0; i < shards; i++ {
+		start := i * cookieShardSize
+		end := start + cookieShardSize
+		if end > len(value) {
+			end = len(value)
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName + "_" + strconv.Itoa(i),
+			Value:    value[start:end],
+			Path:     basePath,
+			HttpOnly: true,
+			Secure:   usingSSL,
+		})
+	}
+	return nil
+}
+
+func readSessionCookie(r *http.Request) string {
+	if c, err := r.Cookie(cookieName); err == nil {
+		return c.Value
+	}
+	var value string
+	for i := 0; i < maxCookieShards; i++ {
+		c, err := r.Cookie(cookieName + "_" + strconv.Itoa(i))
+		if err != nil {
+			break
+		}
+		value += c.Value
+	}
+	return value
+}
+
+// readSessionID returns the opaque session store key for r. It only
+// applies in the default (non-split) cookie mode; callers in
+// split-cookie mode have no session store key to key off of and should
+// use getJwtToken directly instead.
+func readSessionID(r *http.Request) string {
+	if splitCookiesEnabled() {
+		return ""
+	}
+	return readSessionCookie(r)
+}
+
+// sessionIdentifier returns a stable string identifying r's session,
+// used to scope CSRF tokens to a specific login. In the default mode
+// this is the session store key; in split-cookie mode, where there is
+// no store key, it's derived from the claim itself (subject + issue
+// time), which stays constant for the life of the login.
+func sessionIdentifier(r *http.Request) string {
+	if !splitCookiesEnabled() {
+		return readSessionCookie(r)
+	}
+	claim, err := getJwtToken(r)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%v.%v", claim.Id, claim.IssuedAt)
+}
+
+// EndSession logs r out: it revokes the server-side session and clears
+// the session cookie along with any shards written by writeSessionCookie.
+func EndSession(w http.ResponseWriter, r *http.Request) {
+	id := readSessionID(r)
+	if id != "" {
+		_ = sessionStore.Delete(id)
+	}
+	expire := time.Unix(0, 0)
+	http.SetCookie(w, &http.Cookie{Name: cookieName, Value: "", Path: basePath, Expires: expire, MaxAge: -1})
+	for i := 0; i < maxCookieShards; i++ {
+		http.SetCookie(w, &http.Cookie{Name: cookieName + "_" + strconv.Itoa(i), Value: "", Path: basePath, Expires: expire, MaxAge: -1})
+	}
+}
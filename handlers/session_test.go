@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	store := newMemorySessionStore()
+	claim := JwtClaim{Id: "user-1", Email: "user@example.com", Admin: true, IssuedAt: time.Now().Unix()}
+
+	id, err := store.Create(claim, time.Minute)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, ok, err := store.Get(id)
+	if err != nil || !ok {
+		t.Fatalf("Get() = %v, %v, %v; want claim, true, nil", got, ok, err)
+	}
+	if got != claim {
+		t.Errorf("Get() = %+v, want %+v", got, claim)
+	}
+
+	updated := claim
+	updated.Admin = false
+	if err := store.Update(id, updated, time.Minute); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, ok, err = store.Get(id)
+	if err != nil || !ok || got.Admin {
+		t.Fatalf("Get() after Update() = %+v, %v, %v; want Admin=false", got, ok, err)
+	}
+
+	if err := store.Delete(id); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok, _ := store.Get(id); ok {
+		t.Error("Get() after Delete() = ok, want !ok")
+	}
+}
+
+func TestMemorySessionStoreExpiry(t *testing.T) {
+	store := newMemorySessionStore()
+	id, err := store.Create(JwtClaim{Id: "user-1"}, -time.Second)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, ok, _ := store.Get(id); ok {
+		t.Error("Get() on an already-expired session = ok, want !ok")
+	}
+}
+
+func TestMemorySessionStoreUpdateMissing(t *testing.T) {
+	store := newMemorySessionStore()
+	if err := store.Update("nonexistent", JwtClaim{}, time.Minute); err == nil {
+		t.Error("Update() on a nonexistent session = nil error, want error")
+	}
+}
+
+func TestEncodeDecodeClientClaim(t *testing.T) {
+	jwtKey = []byte("test-signing-key")
+	claim := JwtClaim{
+		Id:           "user-1",
+		Email:        "user@example.com",
+		Admin:        true,
+		IssuedAt:     time.Now().Unix(),
+		Provider:     "google",
+		RefreshToken: "super-secret-refresh-token",
+		ExpiresAt:    time.Now().Add(time.Hour).Unix(),
+	}
+
+	token, err := encodeClientClaim(claim)
+	if err != nil {
+		t.Fatalf("encodeClientClaim() error = %v", err)
+	}
+	if strings.Contains(token, "super-secret-refresh-token") {
+		t.Error("encodeClientClaim() leaked RefreshToken into the client-visible token")
+	}
+
+	got, err := decodeClientClaim(token)
+	if err != nil {
+		t.Fatalf("decodeClientClaim() error = %v", err)
+	}
+	want := JwtClaim{Id: claim.Id, Email: claim.Email, Admin: claim.Admin, IssuedAt: claim.IssuedAt}
+	if got != want {
+		t.Errorf("decodeClientClaim() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeClientClaimRejectsTamperedSignature(t *testing.T) {
+	jwtKey = []byte("test-signing-key")
+	token, err := encodeClientClaim(JwtClaim{Id: "user-1", IssuedAt: time.Now().Unix()})
+	if err != nil {
+		t.Fatalf("encodeClientClaim() error = %v", err)
+	}
+
+	dot := strings.LastIndexByte(token, '.')
+	tampered := token[:dot] + "." + "0000000000000000000000000000000000000000000000000000000000000000"
+	if _, err := decodeClientClaim(tampered); err == nil {
+		t.Error("decodeClientClaim() on a tampered signature = nil error, want error")
+	}
+}
+
+func TestDecodeClientClaimRejectsExpired(t *testing.T) {
+	jwtKey = []byte("test-signing-key")
+	stale := time.Now().Add(-sessionTTL - time.Hour).Unix()
+	token, err := encodeClientClaim(JwtClaim{Id: "user-1", IssuedAt: stale})
+	if err != nil {
+		t.Fatalf("encodeClientClaim() error = %v", err)
+	}
+	if _, err := decodeClientClaim(token); err == nil {
+		t.Error("decodeClientClaim() on an expired claim = nil error, want error")
+	}
+}
+
+func TestDecodeClientClaimRejectsMalformed(t *testing.T) {
+	if _, err := decodeClientClaim("not-a-valid-token"); err == nil {
+		t.Error("decodeClientClaim() on a malformed token = nil error, want error")
+	}
+}
+
+func TestWriteReadSessionCookieSingle(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := writeSessionCookie(w, "short-value"); err != nil {
+		t.Fatalf("writeSessionCookie() error = %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	if got := readSessionCookie(r); got != "short-value" {
+		t.Errorf("readSessionCookie() = %q, want %q", got, "short-value")
+	}
+}
+
+func TestWriteReadSessionCookieSharded(t *testing.T) {
+	value := strings.Repeat("a", cookieShardSize*3+100)
+
+	w := httptest.NewRecorder()
+	if err := writeSessionCookie(w, value); err != nil {
+		t.Fatalf("writeSessionCookie() error = %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 4 {
+		t.Fatalf("writeSessionCookie() wrote %v cookies, want 4 shards", len(cookies))
+	}
+	for _, c := range cookies {
+		if len(c.Value) > cookieShardSize {
+			t.Errorf("cookie %v has %v bytes, want <= %v", c.Name, len(c.Value), cookieShardSize)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	if got := readSessionCookie(r); got != value {
+		t.Errorf("readSessionCookie() reassembled %v bytes, want %v", len(got), len(value))
+	}
+}
+
+func TestWriteSessionCookieTooLarge(t *testing.T) {
+	value := strings.Repeat("a", cookieShardSize*(maxCookieShards+1))
+	w := httptest.NewRecorder()
+	if err := writeSessionCookie(w, value); err == nil {
+		t.Error("writeSessionCookie() with a value needing more than maxCookieShards shards = nil error, want error")
+	}
+}